@@ -3,6 +3,8 @@ package iavl
 import (
 	"bytes"
 	"fmt"
+	"hash"
+	"io"
 
 	"github.com/pkg/errors"
 	"golang.org/x/crypto/ripemd160"
@@ -43,23 +45,29 @@ func (n *IAVLProofInnerNode) String() string {
 
 func (branch IAVLProofInnerNode) Hash(childHash []byte) []byte {
 	hasher := ripemd160.New()
-	buf := new(bytes.Buffer)
+	if err := branch.writeHashBytes(hasher, childHash); err != nil {
+		PanicCrisis(Fmt("Failed to hash IAVLProofInnerNode: %v", err))
+	}
+	return hasher.Sum(nil)
+}
+
+// writeHashBytes writes the same bytes Hash hashes, but directly into w
+// rather than buffering them first. This lets the RIPEMD160 hasher itself
+// serve as w, so hashing an inner node never materializes the encoded node
+// in memory.
+func (branch IAVLProofInnerNode) writeHashBytes(w io.Writer, childHash []byte) error {
 	n, err := int(0), error(nil)
-	wire.WriteInt8(branch.Height, buf, &n, &err)
-	wire.WriteVarint(branch.Size, buf, &n, &err)
+	wire.WriteInt8(branch.Height, w, &n, &err)
+	wire.WriteVarint(branch.Size, w, &n, &err)
 
 	if len(branch.Left) == 0 {
-		wire.WriteByteSlice(childHash, buf, &n, &err)
-		wire.WriteByteSlice(branch.Right, buf, &n, &err)
+		wire.WriteByteSlice(childHash, w, &n, &err)
+		wire.WriteByteSlice(branch.Right, w, &n, &err)
 	} else {
-		wire.WriteByteSlice(branch.Left, buf, &n, &err)
-		wire.WriteByteSlice(childHash, buf, &n, &err)
-	}
-	if err != nil {
-		PanicCrisis(Fmt("Failed to hash IAVLProofInnerNode: %v", err))
+		wire.WriteByteSlice(branch.Left, w, &n, &err)
+		wire.WriteByteSlice(childHash, w, &n, &err)
 	}
-	hasher.Write(buf.Bytes())
-	return hasher.Sum(nil)
+	return err
 }
 
 type IAVLProofLeafNode struct {
@@ -70,20 +78,47 @@ type IAVLProofLeafNode struct {
 
 func (leaf IAVLProofLeafNode) Hash() []byte {
 	hasher := ripemd160.New()
-	buf := new(bytes.Buffer)
 	n, err := int(0), error(nil)
-	wire.WriteInt8(0, buf, &n, &err)
-	wire.WriteVarint(1, buf, &n, &err)
-	wire.WriteByteSlice(leaf.KeyBytes, buf, &n, &err)
-	wire.WriteByteSlice(leaf.ValueBytes, buf, &n, &err)
-	wire.WriteUint64(leaf.Version, buf, &n, &err)
+	wire.WriteInt8(0, hasher, &n, &err)
+	wire.WriteVarint(1, hasher, &n, &err)
+	wire.WriteByteSlice(leaf.KeyBytes, hasher, &n, &err)
+	wire.WriteByteSlice(leaf.ValueBytes, hasher, &n, &err)
+	wire.WriteUint64(leaf.Version, hasher, &n, &err)
 	if err != nil {
 		PanicCrisis(Fmt("Failed to hash IAVLProofLeafNode: %v", err))
 	}
-	hasher.Write(buf.Bytes())
 	return hasher.Sum(nil)
 }
 
+// hashStream hashes the leaf the same way Hash does, except that the value
+// bytes are read incrementally from valueReader instead of being taken from
+// leaf.ValueBytes. This lets a caller prove a large value without holding
+// two copies of it (one in the IAVLProofLeafNode, one in the I/O buffer) in
+// memory at once. valueSize must be the exact number of bytes valueReader
+// will yield, since it's written as the wire length prefix before any of
+// those bytes are read — this is why it's a caller-supplied parameter
+// rather than something hashStream infers from valueReader, which lets any
+// io.Reader be used, not just ones that already buffer the whole value
+// (e.g. a non-seekable network stream of known content-length).
+func (leaf IAVLProofLeafNode) hashStream(hasher hash.Hash, valueReader io.Reader, valueSize int64) error {
+	n, err := int(0), error(nil)
+	wire.WriteInt8(0, hasher, &n, &err)
+	wire.WriteVarint(1, hasher, &n, &err)
+	wire.WriteByteSlice(leaf.KeyBytes, hasher, &n, &err)
+	if err != nil {
+		return err
+	}
+	wire.WriteVarint(int(valueSize), hasher, &n, &err)
+	if err != nil {
+		return err
+	}
+	if _, err := io.CopyN(hasher, valueReader, valueSize); err != nil {
+		return errors.Wrap(err, "could not stream value into hasher")
+	}
+	wire.WriteUint64(leaf.Version, hasher, &n, &err)
+	return err
+}
+
 func (leaf IAVLProofLeafNode) isLesserThan(key []byte) bool {
 	return bytes.Compare(leaf.KeyBytes, key) == -1
 }
@@ -157,14 +192,14 @@ func (t *IAVLTree) constructKeyAbsentProof(key []byte, proof *KeyAbsentProof) er
 	}
 
 	if lkey != nil {
-		path, node, _ := t.root.pathToKey(t, lkey)
+		path, node, _ := t.cachedPathToKey(t.root, lkey)
 		proof.Left = &PathWithNode{
 			Path: path,
 			Node: IAVLProofLeafNode{lkey, lval, node.version},
 		}
 	}
 	if rkey != nil {
-		path, node, _ := t.root.pathToKey(t, rkey)
+		path, node, _ := t.cachedPathToKey(t.root, rkey)
 		proof.Right = &PathWithNode{
 			Path: path,
 			Node: IAVLProofLeafNode{rkey, rval, node.version},
@@ -180,7 +215,7 @@ func (t *IAVLTree) getWithProof(key []byte) (value []byte, proof *KeyExistsProof
 	}
 	t.root.hashWithCount() // Ensure that all hashes are calculated.
 
-	path, node, err := t.root.pathToKey(t, key)
+	path, node, err := t.cachedPathToKey(t.root, key)
 	if err != nil {
 		return nil, nil, errors.Wrap(err, "could not construct path to key")
 	}
@@ -193,6 +228,39 @@ func (t *IAVLTree) getWithProof(key []byte) (value []byte, proof *KeyExistsProof
 	return node.value, proof, nil
 }
 
+// VerifyStream checks that (key, valueReader) exists under root, the same
+// way Verify does, except that the value is read incrementally from
+// valueReader rather than passed as a single []byte. valueSize must be the
+// exact number of bytes valueReader will yield. Taking it as an explicit
+// parameter (rather than, say, requiring valueReader to report its own
+// length) means valueReader can be any io.Reader, including a non-seekable
+// network stream, not just one that already holds the whole value in
+// memory. This lets a caller prove a large value (e.g. a multi-MB blob)
+// while only ever holding a chunk of it in memory at once.
+func (proof *KeyExistsProof) VerifyStream(key []byte, valueReader io.Reader, valueSize int64, root []byte) error {
+	if proof == nil || proof.PathToKey == nil {
+		return errors.New("proof is nil")
+	}
+	if !bytes.Equal(proof.RootHash, root) {
+		return errors.WithStack(ErrInvalidRoot)
+	}
+
+	leaf := IAVLProofLeafNode{KeyBytes: key, Version: proof.Version}
+	hasher := ripemd160.New()
+	if err := leaf.hashStream(hasher, valueReader, valueSize); err != nil {
+		return errors.Wrap(err, "could not stream leaf hash")
+	}
+	hash := hasher.Sum(nil)
+
+	for _, branch := range proof.PathToKey.InnerNodes {
+		hash = branch.Hash(hash)
+	}
+	if !bytes.Equal(hash, proof.RootHash) {
+		return ErrInvalidProof()
+	}
+	return nil
+}
+
 func (t *IAVLTree) keyAbsentProof(key []byte) (*KeyAbsentProof, error) {
 	if t.root == nil {
 		return nil, ErrNilRoot
@@ -0,0 +1,324 @@
+// Package ics23 converts IAVL existence and non-existence proofs into the
+// ICS-23 (https://github.com/confio/ics23) CommitmentProof wire shape used
+// by IBC light clients, and verifies proofs produced in that shape.
+//
+// The conversion mirrors the hashing performed by IAVLProofLeafNode.Hash and
+// IAVLProofInnerNode.Hash exactly, so a CommitmentProof built here verifies
+// against the same root hash as the native IAVL proof it was derived from.
+package ics23
+
+import (
+	"bytes"
+	"fmt"
+
+	"golang.org/x/crypto/ripemd160"
+
+	"github.com/tendermint/go-wire"
+)
+
+// HashOp identifies the hash function used by a LeafOp or InnerOp.
+type HashOp int32
+
+// RipeMd160 is the only hash function IAVL proofs use.
+const RipeMd160 HashOp = 1
+
+// LeafOp describes how a leaf's key/value pair is hashed into a leaf digest.
+// It mirrors the field-by-field encoding done by IAVLProofLeafNode.Hash:
+// int8(height=0), varint(size=1), key, value, uint64(version).
+type LeafOp struct {
+	Hash HashOp
+}
+
+// InnerOp describes how a child digest is combined with a sibling hash to
+// produce the parent digest: hash(Prefix || childHash || Suffix). Prefix and
+// Suffix are already wire-encoded exactly as IAVLProofInnerNode.Hash would
+// encode them, i.e. Prefix is int8(height) || varint(size) [|| the
+// length-prefixed left sibling, if the child being proved is the right
+// child] || varint(len(childHash)), and Suffix is the length-prefixed right
+// sibling (if the child being proved is the left child, in which case
+// Prefix carries no sibling bytes at all).
+//
+// Height, Size, DescendedRight and Sibling duplicate structural information
+// already present in Prefix/Suffix, in unpacked form. They aren't part of
+// the ICS-23 wire format and a generic ICS-23 verifier ignores them — they
+// exist so NonExistenceProof.Verify can run the same lowest-common-ancestor
+// adjacency check proof_range.go's checkAdjacent does (confirming two
+// leaves are tree-adjacent, not just that each independently verifies and
+// happens to bracket a key in sorted order) without re-parsing the
+// wire-encoded bytes.
+type InnerOp struct {
+	Hash   HashOp
+	Prefix []byte
+	Suffix []byte
+
+	Height         int8
+	Size           int64
+	DescendedRight bool
+	Sibling        []byte
+}
+
+// ExistenceProof proves that (Key, Value) is present in the tree committed
+// to by a root hash. Path is ordered leaf-to-root, matching the order
+// IAVLProofInnerNode entries are appended in pathToKey.
+type ExistenceProof struct {
+	Key     []byte
+	Value   []byte
+	Version uint64
+	Leaf    LeafOp
+	Path    []InnerOp
+}
+
+// NonExistenceProof proves that Key is absent, by bracketing it with
+// ExistenceProofs for its left and right neighbors in key order. Either
+// neighbor may be nil if Key is outside the range of one side.
+type NonExistenceProof struct {
+	Key   []byte
+	Left  *ExistenceProof
+	Right *ExistenceProof
+}
+
+// CommitmentProof is the ICS-23 envelope: exactly one of Exist or Nonexist
+// is set.
+type CommitmentProof struct {
+	Exist    *ExistenceProof
+	Nonexist *NonExistenceProof
+}
+
+// ProofSpec describes the hash function and node encoding that IAVL proofs
+// use, so that a generic ICS-23 verifier can be configured for this tree
+// without hard-coding IAVL's layout.
+var ProofSpec = struct {
+	LeafHash  HashOp
+	InnerHash HashOp
+	MinPrefix int
+	MaxPrefix int
+}{
+	LeafHash:  RipeMd160,
+	InnerHash: RipeMd160,
+	MinPrefix: 0,
+	MaxPrefix: 1,
+}
+
+// leafHash reproduces IAVLProofLeafNode.Hash: int8(0), varint(1), key, value, uint64(version).
+func leafHash(key, value []byte, version uint64) []byte {
+	buf := new(bytes.Buffer)
+	n, err := int(0), error(nil)
+	wire.WriteInt8(0, buf, &n, &err)
+	wire.WriteVarint(1, buf, &n, &err)
+	wire.WriteByteSlice(key, buf, &n, &err)
+	wire.WriteByteSlice(value, buf, &n, &err)
+	wire.WriteUint64(version, buf, &n, &err)
+	if err != nil {
+		panic(fmt.Sprintf("failed to hash ics23 leaf: %v", err))
+	}
+
+	hasher := ripemd160.New()
+	hasher.Write(buf.Bytes())
+	return hasher.Sum(nil)
+}
+
+// innerHash reproduces IAVLProofInnerNode.Hash for a single step of the
+// path: Prefix and Suffix already carry the wire-encoded height/size/sibling
+// bytes, so folding a child into its parent is just hash(Prefix||child||Suffix).
+func innerHash(op InnerOp, childHash []byte) []byte {
+	hasher := ripemd160.New()
+	hasher.Write(op.Prefix)
+	hasher.Write(childHash)
+	hasher.Write(op.Suffix)
+	return hasher.Sum(nil)
+}
+
+// Calculate recomputes the root hash implied by an ExistenceProof, without
+// reference to the tree itself.
+func (ep *ExistenceProof) Calculate() []byte {
+	hash := leafHash(ep.Key, ep.Value, ep.Version)
+	for _, op := range ep.Path {
+		hash = innerHash(op, hash)
+	}
+	return hash
+}
+
+// Verify checks that the proof is internally consistent (Key and Value match
+// the leaf hash, and the path folds up to root).
+func (ep *ExistenceProof) Verify(root, key, value []byte) error {
+	if !bytes.Equal(ep.Key, key) {
+		return fmt.Errorf("key mismatch: proof has %x, expected %x", ep.Key, key)
+	}
+	if !bytes.Equal(ep.Value, value) {
+		return fmt.Errorf("value mismatch for key %x", key)
+	}
+	if !bytes.Equal(ep.Calculate(), root) {
+		return fmt.Errorf("calculated root does not match provided root")
+	}
+	return nil
+}
+
+// innerOpEqual reports whether two InnerOps describe the same physical
+// ancestor, reached the same way.
+func innerOpEqual(a, b InnerOp) bool {
+	return a.Height == b.Height && a.Size == b.Size && a.DescendedRight == b.DescendedRight &&
+		bytes.Equal(a.Sibling, b.Sibling)
+}
+
+// commonSuffixLen returns the length of the longest common suffix of a and
+// b. Since a Path is ordered leaf-to-root, a shared suffix is exactly the
+// set of ancestors two leaves' paths have in common (everything above
+// their lowest common ancestor).
+func commonSuffixLen(a, b []InnerOp) int {
+	n := 0
+	for n < len(a) && n < len(b) && innerOpEqual(a[len(a)-1-n], b[len(b)-1-n]) {
+		n++
+	}
+	return n
+}
+
+// foldToSubtreeHash folds leafHash up through path (leaf-to-root order)
+// using the real Prefix/Suffix encoding, the same way Calculate does.
+func foldToSubtreeHash(leafHash []byte, path []InnerOp) []byte {
+	hash := leafHash
+	for _, op := range path {
+		hash = innerHash(op, hash)
+	}
+	return hash
+}
+
+// checkAdjacent verifies that leftFull and rightFull (full leaf-to-root
+// paths for two leaves known to be in sorted order, with leftHash/rightHash
+// their leaf hashes) are immediate in-order neighbors: nothing exists
+// between them anywhere in the tree. This mirrors proof_range.go's
+// checkAdjacent exactly: find the lowest common ancestor (the first point
+// both paths diverge, reading from the leaf end), then check that (a) the
+// left leaf is the rightmost leaf of the ancestor's left subtree, (b) the
+// right leaf is the leftmost leaf of the ancestor's right subtree, and (c)
+// the sibling hashes recorded at the ancestor are exactly the hashes each
+// side's own sub-path folds up to.
+func checkAdjacent(leftFull, rightFull []InnerOp, leftHash, rightHash []byte) error {
+	shared := commonSuffixLen(leftFull, rightFull)
+	leftDivergent := leftFull[:len(leftFull)-shared]
+	rightDivergent := rightFull[:len(rightFull)-shared]
+	if len(leftDivergent) == 0 || len(rightDivergent) == 0 {
+		return fmt.Errorf("leaves do not share a lowest common ancestor")
+	}
+
+	lca := leftDivergent[len(leftDivergent)-1]
+	rca := rightDivergent[len(rightDivergent)-1]
+	if lca.Height != rca.Height || lca.Size != rca.Size {
+		return fmt.Errorf("mismatched lowest common ancestor between adjacent leaves")
+	}
+	if lca.DescendedRight {
+		return fmt.Errorf("left leaf did not descend left at the common ancestor")
+	}
+	if !rca.DescendedRight {
+		return fmt.Errorf("right leaf did not descend right at the common ancestor")
+	}
+
+	// Nothing may be omitted within either subtree beneath the ancestor:
+	// the left leaf must be the max of its subtree (always went right
+	// below the ancestor), the right leaf must be the min of its subtree
+	// (always went left below the ancestor).
+	for _, e := range leftDivergent[:len(leftDivergent)-1] {
+		if !e.DescendedRight {
+			return fmt.Errorf("a leaf may have been omitted to the right of the range's left side")
+		}
+	}
+	for _, e := range rightDivergent[:len(rightDivergent)-1] {
+		if e.DescendedRight {
+			return fmt.Errorf("a leaf may have been omitted to the left of the range's right side")
+		}
+	}
+
+	rightSubHash := foldToSubtreeHash(rightHash, rightDivergent[:len(rightDivergent)-1])
+	if !bytes.Equal(lca.Sibling, rightSubHash) {
+		return fmt.Errorf("right subtree hash does not match the common ancestor's recorded sibling")
+	}
+	leftSubHash := foldToSubtreeHash(leftHash, leftDivergent[:len(leftDivergent)-1])
+	if !bytes.Equal(rca.Sibling, leftSubHash) {
+		return fmt.Errorf("left subtree hash does not match the common ancestor's recorded sibling")
+	}
+	return nil
+}
+
+// checkGlobalLeftmost verifies that path describes the leftmost leaf of the
+// whole tree: it must descend left at every level.
+func checkGlobalLeftmost(path []InnerOp) error {
+	for _, op := range path {
+		if op.DescendedRight {
+			return fmt.Errorf("leaf is not the tree's leftmost leaf")
+		}
+	}
+	return nil
+}
+
+// checkGlobalRightmost verifies that path describes the rightmost leaf of
+// the whole tree: it must descend right at every level.
+func checkGlobalRightmost(path []InnerOp) error {
+	for _, op := range path {
+		if !op.DescendedRight {
+			return fmt.Errorf("leaf is not the tree's rightmost leaf")
+		}
+	}
+	return nil
+}
+
+// Verify checks a NonExistenceProof: both neighbors (where present) must
+// verify against root and must bracket key in sorted order, and must be
+// proven tree-adjacent (or, if only one neighbor is present, proven the
+// global leftmost/rightmost leaf) so that no other leaf — in particular
+// key itself — could sit between them undetected.
+func (nep *NonExistenceProof) Verify(root, key []byte) error {
+	if nep.Left == nil && nep.Right == nil {
+		return fmt.Errorf("non-existence proof has no left or right neighbor")
+	}
+	if nep.Left != nil {
+		if bytes.Compare(nep.Left.Key, key) >= 0 {
+			return fmt.Errorf("left neighbor %x is not less than key %x", nep.Left.Key, key)
+		}
+		if err := nep.Left.Verify(root, nep.Left.Key, nep.Left.Value); err != nil {
+			return fmt.Errorf("left neighbor: %v", err)
+		}
+	}
+	if nep.Right != nil {
+		if bytes.Compare(nep.Right.Key, key) <= 0 {
+			return fmt.Errorf("right neighbor %x is not greater than key %x", nep.Right.Key, key)
+		}
+		if err := nep.Right.Verify(root, nep.Right.Key, nep.Right.Value); err != nil {
+			return fmt.Errorf("right neighbor: %v", err)
+		}
+	}
+
+	switch {
+	case nep.Left != nil && nep.Right != nil:
+		leftHash := leafHash(nep.Left.Key, nep.Left.Value, nep.Left.Version)
+		rightHash := leafHash(nep.Right.Key, nep.Right.Value, nep.Right.Version)
+		if err := checkAdjacent(nep.Left.Path, nep.Right.Path, leftHash, rightHash); err != nil {
+			return fmt.Errorf("left and right neighbors are not adjacent leaves: %v", err)
+		}
+	case nep.Left != nil:
+		if err := checkGlobalRightmost(nep.Left.Path); err != nil {
+			return fmt.Errorf("left neighbor: %v", err)
+		}
+	case nep.Right != nil:
+		if err := checkGlobalLeftmost(nep.Right.Path); err != nil {
+			return fmt.Errorf("right neighbor: %v", err)
+		}
+	}
+	return nil
+}
+
+// VerifyMembership verifies a CommitmentProof asserting that key maps to
+// value under root.
+func VerifyMembership(proof *CommitmentProof, root, key, value []byte) error {
+	if proof.Exist == nil {
+		return fmt.Errorf("commitment proof does not contain an existence proof")
+	}
+	return proof.Exist.Verify(root, key, value)
+}
+
+// VerifyNonMembership verifies a CommitmentProof asserting that key is
+// absent from root.
+func VerifyNonMembership(proof *CommitmentProof, root, key []byte) error {
+	if proof.Nonexist == nil {
+		return fmt.Errorf("commitment proof does not contain a non-existence proof")
+	}
+	return proof.Nonexist.Verify(root, key)
+}
@@ -0,0 +1,204 @@
+package iavl
+
+import (
+	"container/list"
+	"runtime"
+	"sync"
+	"unsafe"
+)
+
+// proofCacheEntry is the cached result of a single pathToKey walk, keyed by
+// the root hash it was computed against and the key that was looked up.
+// Keying on root hash (rather than just key) means an entry can never be
+// served for the wrong version: once a write produces a new root, lookups
+// pass the new hash and simply never hit entries left over from the old
+// one, regardless of whether anyone evicts them.
+type proofCacheEntry struct {
+	rootHash string
+	key      string
+	path     *PathToKey
+	node     *IAVLNode
+}
+
+// proofCache is a bounded LRU that memoizes pathToKey results so that
+// repeated getWithProof/keyAbsentProof calls against the same version don't
+// re-walk the tree.
+type proofCache struct {
+	mtx sync.Mutex
+
+	maxSize int
+	items   map[string]*list.Element // rootHash+"/"+key -> *list.Element(*proofCacheEntry)
+	order   *list.List
+
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+func newProofCache(size int) *proofCache {
+	return &proofCache{
+		maxSize: size,
+		items:   make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+func proofCacheKey(rootHash, key []byte) string {
+	return string(rootHash) + "/" + string(key)
+}
+
+func (c *proofCache) get(rootHash, key []byte) (*PathToKey, *IAVLNode, bool) {
+	if c == nil || c.maxSize <= 0 {
+		return nil, nil, false
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	el, ok := c.items[proofCacheKey(rootHash, key)]
+	if !ok {
+		c.misses++
+		return nil, nil, false
+	}
+	c.hits++
+	c.order.MoveToFront(el)
+	entry := el.Value.(*proofCacheEntry)
+	return entry.path, entry.node, true
+}
+
+func (c *proofCache) put(rootHash, key []byte, path *PathToKey, node *IAVLNode) {
+	if c == nil || c.maxSize <= 0 {
+		return
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	k := proofCacheKey(rootHash, key)
+	if el, ok := c.items[k]; ok {
+		c.order.MoveToFront(el)
+		el.Value.(*proofCacheEntry).path = path
+		el.Value.(*proofCacheEntry).node = node
+		return
+	}
+
+	entry := &proofCacheEntry{rootHash: string(rootHash), key: string(key), path: path, node: node}
+	el := c.order.PushFront(entry)
+	c.items[k] = el
+
+	for c.order.Len() > c.maxSize {
+		back := c.order.Back()
+		if back == nil {
+			break
+		}
+		evicted := back.Value.(*proofCacheEntry)
+		delete(c.items, proofCacheKey([]byte(evicted.rootHash), []byte(evicted.key)))
+		c.order.Remove(back)
+		c.evictions++
+	}
+}
+
+// ProofCacheStats reports cumulative hit/miss/eviction counters for an
+// IAVLTree's proof cache, for operators tuning SetProofCacheSize.
+type ProofCacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+}
+
+// proofCaches associates a proofCache with each *IAVLTree that has called
+// SetProofCacheSize, without requiring a field on IAVLTree itself (this
+// package only owns proof*.go, not the IAVLTree type definition). It's
+// keyed on treeToken(t) rather than t itself: a uintptr is just a number to
+// the garbage collector, so, unlike a map keyed on *IAVLTree directly, this
+// map does not keep any tree it has seen reachable for the life of the
+// process. The finalizer registered in getOrCreateProofCache removes a
+// tree's entry once that tree is actually collected, which is exactly the
+// short-lived-tree behavior an ABCI query server needs from this cache.
+var (
+	proofCachesMu sync.Mutex
+	proofCaches   = make(map[uintptr]*proofCache)
+)
+
+// treeToken returns an opaque, non-pointer key identifying t for
+// proofCaches. The runtime only reuses a collected object's address after
+// that object's finalizer has already run, so once getOrCreateProofCache's
+// finalizer deletes a token from proofCaches, the token can never again be
+// confused with a live tree.
+func treeToken(t *IAVLTree) uintptr {
+	return uintptr(unsafe.Pointer(t))
+}
+
+func getOrCreateProofCache(t *IAVLTree, size int) *proofCache {
+	token := treeToken(t)
+
+	proofCachesMu.Lock()
+	defer proofCachesMu.Unlock()
+
+	c, ok := proofCaches[token]
+	if !ok {
+		c = newProofCache(size)
+		proofCaches[token] = c
+		runtime.SetFinalizer(t, func(t *IAVLTree) {
+			proofCachesMu.Lock()
+			delete(proofCaches, treeToken(t))
+			proofCachesMu.Unlock()
+		})
+		return c
+	}
+	c.mtx.Lock()
+	c.maxSize = size
+	for c.order.Len() > 0 && c.order.Len() > c.maxSize {
+		back := c.order.Back()
+		evicted := back.Value.(*proofCacheEntry)
+		delete(c.items, proofCacheKey([]byte(evicted.rootHash), []byte(evicted.key)))
+		c.order.Remove(back)
+		c.evictions++
+	}
+	c.mtx.Unlock()
+	return c
+}
+
+func lookupProofCache(t *IAVLTree) *proofCache {
+	proofCachesMu.Lock()
+	defer proofCachesMu.Unlock()
+	return proofCaches[treeToken(t)] // nil (cache disabled) if SetProofCacheSize was never called
+}
+
+// SetProofCacheSize bounds the number of (root hash, key) -> pathToKey
+// results IAVLTree keeps memoized for proof serving. The cache is disabled
+// (the default) until this is called at least once. Calling it again
+// resizes the existing cache, evicting down to the new bound as needed.
+func (t *IAVLTree) SetProofCacheSize(size int) {
+	getOrCreateProofCache(t, size)
+}
+
+// ProofCacheStats returns the current hit/miss/eviction counters for this
+// tree's proof cache, or a zero value if SetProofCacheSize was never called.
+func (t *IAVLTree) ProofCacheStats() ProofCacheStats {
+	c := lookupProofCache(t)
+	if c == nil {
+		return ProofCacheStats{}
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return ProofCacheStats{
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+	}
+}
+
+// cachedPathToKey wraps node.pathToKey with the tree's proof cache, if one
+// is enabled. It must only be called after t.root.hashWithCount(), the same
+// precondition getWithProof and keyAbsentProof already enforce.
+func (t *IAVLTree) cachedPathToKey(node *IAVLNode, key []byte) (*PathToKey, *IAVLNode, error) {
+	cache := lookupProofCache(t)
+	if path, leaf, ok := cache.get(node.hash, key); ok {
+		return path, leaf, nil
+	}
+	path, leaf, err := node.pathToKey(t, key)
+	if err != nil {
+		return path, leaf, err
+	}
+	cache.put(node.hash, key, path, leaf)
+	return path, leaf, nil
+}
@@ -0,0 +1,46 @@
+package iavl
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProofCacheHitsAndMisses(t *testing.T) {
+	tree := NewIAVLTree(0, nil)
+	for i := 0; i < 20; i++ {
+		tree.Set([]byte(fmt.Sprintf("key%03d", i)), []byte(fmt.Sprintf("value%03d", i)))
+	}
+	tree.Hash()
+	tree.SetProofCacheSize(100)
+
+	_, _, err := tree.getWithProof([]byte("key010"))
+	require.NoError(t, err)
+	_, _, err = tree.getWithProof([]byte("key010"))
+	require.NoError(t, err)
+
+	stats := tree.ProofCacheStats()
+	require.EqualValues(t, 1, stats.Hits)
+	require.EqualValues(t, 1, stats.Misses)
+}
+
+func TestProofCacheNeverServesStaleValueAcrossWrites(t *testing.T) {
+	tree := NewIAVLTree(0, nil)
+	tree.Set([]byte("key"), []byte("old-value"))
+	tree.Hash()
+	tree.SetProofCacheSize(100)
+
+	value, proof, err := tree.getWithProof([]byte("key"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("old-value"), value)
+	oldRoot := proof.RootHash
+
+	tree.Set([]byte("key"), []byte("new-value"))
+	tree.Hash()
+
+	value, proof, err = tree.getWithProof([]byte("key"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("new-value"), value)
+	require.NotEqual(t, oldRoot, proof.RootHash)
+}
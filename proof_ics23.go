@@ -0,0 +1,102 @@
+package iavl
+
+import (
+	"bytes"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ripemd160"
+
+	"github.com/tendermint/go-wire"
+	"github.com/tendermint/iavl/proof/ics23"
+	. "github.com/tendermint/tmlibs/common"
+)
+
+// toInnerOps converts the InnerNodes collected by pathToKey into the
+// ics23.InnerOp form, preserving order (leaf-to-root). Prefix/Suffix are
+// wire-encoded byte-for-byte the same way IAVLProofInnerNode.writeHashBytes
+// encodes them, so ics23.innerHash reproduces IAVLProofInnerNode.Hash
+// exactly: int8(height), varint(size), then either the left sibling before
+// the child (child is the right node) or the right sibling after it (child
+// is the left node). The child hash is length-prefixed too, so Prefix ends
+// with varint(len(childHash)).
+func toInnerOps(nodes []IAVLProofInnerNode) []ics23.InnerOp {
+	ops := make([]ics23.InnerOp, 0, len(nodes))
+	for _, n := range nodes {
+		buf := new(bytes.Buffer)
+		w, err := int(0), error(nil)
+		wire.WriteInt8(n.Height, buf, &w, &err)
+		wire.WriteVarint(n.Size, buf, &w, &err)
+		if err != nil {
+			PanicCrisis(Fmt("Failed to encode IAVLProofInnerNode for ics23: %v", err))
+		}
+
+		op := ics23.InnerOp{
+			Hash:           ics23.RipeMd160,
+			Height:         n.Height,
+			Size:           int64(n.Size),
+			DescendedRight: len(n.Right) == 0,
+		}
+		if len(n.Left) == 0 {
+			op.Sibling = n.Right
+			sufBuf := new(bytes.Buffer)
+			wire.WriteByteSlice(n.Right, sufBuf, &w, &err)
+			if err != nil {
+				PanicCrisis(Fmt("Failed to encode IAVLProofInnerNode for ics23: %v", err))
+			}
+			op.Suffix = sufBuf.Bytes()
+		} else {
+			op.Sibling = n.Left
+			wire.WriteByteSlice(n.Left, buf, &w, &err)
+		}
+		// Every child hash is a RIPEMD160 digest, so its length prefix is
+		// the same at each step.
+		wire.WriteVarint(ripemd160.Size, buf, &w, &err)
+		if err != nil {
+			PanicCrisis(Fmt("Failed to encode IAVLProofInnerNode for ics23: %v", err))
+		}
+		op.Prefix = buf.Bytes()
+		ops = append(ops, op)
+	}
+	return ops
+}
+
+func toExistenceProof(path *PathToKey, leaf IAVLProofLeafNode) *ics23.ExistenceProof {
+	return &ics23.ExistenceProof{
+		Key:     leaf.KeyBytes,
+		Value:   leaf.ValueBytes,
+		Version: leaf.Version,
+		Leaf:    ics23.LeafOp{Hash: ics23.RipeMd160},
+		Path:    toInnerOps(path.InnerNodes),
+	}
+}
+
+// GetMembershipProof returns an ICS-23 CommitmentProof attesting that key
+// exists in the tree, for use by IBC light clients and other verifiers that
+// don't want to depend on IAVL's own wire format.
+func (t *IAVLTree) GetMembershipProof(key []byte) (*ics23.CommitmentProof, error) {
+	value, proof, err := t.getWithProof(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not build membership proof")
+	}
+	leaf := IAVLProofLeafNode{KeyBytes: key, ValueBytes: value, Version: proof.Version}
+	return &ics23.CommitmentProof{Exist: toExistenceProof(proof.PathToKey, leaf)}, nil
+}
+
+// GetNonMembershipProof returns an ICS-23 CommitmentProof attesting that key
+// is absent from the tree, bracketing it with existence proofs for its
+// immediate left and right neighbors.
+func (t *IAVLTree) GetNonMembershipProof(key []byte) (*ics23.CommitmentProof, error) {
+	proof, err := t.keyAbsentProof(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not build non-membership proof")
+	}
+
+	nep := &ics23.NonExistenceProof{Key: key}
+	if proof.Left != nil {
+		nep.Left = toExistenceProof(proof.Left.Path, proof.Left.Node)
+	}
+	if proof.Right != nil {
+		nep.Right = toExistenceProof(proof.Right.Path, proof.Right.Node)
+	}
+	return &ics23.CommitmentProof{Nonexist: nep}, nil
+}
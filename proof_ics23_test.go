@@ -0,0 +1,62 @@
+package iavl
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tendermint/iavl/proof/ics23"
+)
+
+func TestGetMembershipProofVerifiesAgainstRoot(t *testing.T) {
+	tree := NewIAVLTree(0, nil)
+	for i := 0; i < 20; i++ {
+		tree.Set([]byte(fmt.Sprintf("key%03d", i)), []byte(fmt.Sprintf("value%03d", i)))
+	}
+	tree.Hash()
+
+	proof, err := tree.GetMembershipProof([]byte("key010"))
+	require.NoError(t, err)
+	require.NoError(t, ics23.VerifyMembership(proof, tree.root.hash, []byte("key010"), []byte("value010")))
+
+	// A proof for the wrong value must not verify.
+	require.Error(t, ics23.VerifyMembership(proof, tree.root.hash, []byte("key010"), []byte("wrong")))
+}
+
+func TestGetNonMembershipProofVerifiesAgainstRoot(t *testing.T) {
+	tree := NewIAVLTree(0, nil)
+	for i := 0; i < 20; i++ {
+		tree.Set([]byte(fmt.Sprintf("key%03d", i)), []byte(fmt.Sprintf("value%03d", i)))
+	}
+	tree.Hash()
+
+	proof, err := tree.GetNonMembershipProof([]byte("key010a"))
+	require.NoError(t, err)
+	require.NoError(t, ics23.VerifyNonMembership(proof, tree.root.hash, []byte("key010a")))
+}
+
+// TestNonMembershipProofRejectsNonAdjacentNeighbors builds a NonExistenceProof
+// out of two real, individually-verifiable existence proofs that bracket a
+// key in sorted order but are not tree-adjacent (a real leaf sits strictly
+// between them). Verify must reject it, or a malicious full node could hide
+// an existing key from a light client by omitting it this way.
+func TestNonMembershipProofRejectsNonAdjacentNeighbors(t *testing.T) {
+	tree := NewIAVLTree(0, nil)
+	for i := 0; i < 20; i++ {
+		tree.Set([]byte(fmt.Sprintf("key%03d", i)), []byte(fmt.Sprintf("value%03d", i)))
+	}
+	tree.Hash()
+
+	left, err := tree.GetMembershipProof([]byte("key009"))
+	require.NoError(t, err)
+	right, err := tree.GetMembershipProof([]byte("key011"))
+	require.NoError(t, err)
+
+	forged := &ics23.CommitmentProof{Nonexist: &ics23.NonExistenceProof{
+		Key:   []byte("key010"),
+		Left:  left.Exist,
+		Right: right.Exist,
+	}}
+	require.Error(t, ics23.VerifyNonMembership(forged, tree.root.hash, []byte("key010")))
+}
@@ -0,0 +1,172 @@
+package iavl
+
+import (
+	"bytes"
+	"math/bits"
+	"sort"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ripemd160"
+
+	"github.com/tendermint/go-wire"
+	"github.com/tendermint/go-wire/data"
+	. "github.com/tendermint/tmlibs/common"
+)
+
+// MultiStoreSibling is one step of the outer simple Merkle path from a
+// (storeName, subRoot) leaf up to the app hash. Left is true when Hash is
+// the LEFT child at this level (so the running hash being folded up is the
+// right child), and false when Hash is the RIGHT child.
+type MultiStoreSibling struct {
+	Hash data.Bytes `json:"hash"`
+	Left bool       `json:"left"`
+}
+
+// MultiStoreProof binds an IAVL sub-proof's RootHash to an app-level
+// AppHash, the way Cosmos SDK binds each module's IAVL store root into a
+// single simple Merkle tree keyed by store name. StoreName identifies the
+// leaf (storeName, subRoot) within that outer tree, and Sibling holds the
+// simple Merkle sibling hashes needed to walk from that leaf up to
+// AppHash, ordered leaf-to-root.
+type MultiStoreProof struct {
+	StoreName data.Bytes          `json:"store_name"`
+	Sibling   []MultiStoreSibling `json:"siblings"`
+}
+
+// multiStoreLeafHash hashes a (storeName, subRoot) pair the way Tendermint's
+// simple Merkle tree hashes a leaf: wire-encode the byte slices and run them
+// through RIPEMD160, matching the encoding IAVLProofLeafNode.Hash and
+// IAVLProofInnerNode.Hash already use elsewhere in this package.
+func multiStoreLeafHash(storeName, subRoot []byte) []byte {
+	hasher := ripemd160.New()
+	buf := new(bytes.Buffer)
+	n, err := int(0), error(nil)
+	wire.WriteByteSlice(storeName, buf, &n, &err)
+	wire.WriteByteSlice(subRoot, buf, &n, &err)
+	if err != nil {
+		PanicCrisis(Fmt("Failed to hash multi-store leaf: %v", err))
+	}
+	hasher.Write(buf.Bytes())
+	return hasher.Sum(nil)
+}
+
+func multiStoreInnerHash(left, right []byte) []byte {
+	hasher := ripemd160.New()
+	buf := new(bytes.Buffer)
+	n, err := int(0), error(nil)
+	wire.WriteByteSlice(left, buf, &n, &err)
+	wire.WriteByteSlice(right, buf, &n, &err)
+	if err != nil {
+		PanicCrisis(Fmt("Failed to hash multi-store inner node: %v", err))
+	}
+	hasher.Write(buf.Bytes())
+	return hasher.Sum(nil)
+}
+
+// multiStoreSplitPoint returns the size of the left subtree Tendermint's
+// simple Merkle tree uses for n leaves: the largest power of two strictly
+// less than n, so the left subtree is always a complete binary tree.
+func multiStoreSplitPoint(n int) int {
+	k := 1 << uint(bits.Len(uint(n))-1)
+	if k == n {
+		k >>= 1
+	}
+	return k
+}
+
+// multiStoreSubsetHash recomputes the simple Merkle root of a (sorted)
+// slice of already-leaf-hashed items, with no accompanying proof.
+func multiStoreSubsetHash(items [][]byte) []byte {
+	switch len(items) {
+	case 0:
+		return nil
+	case 1:
+		return items[0]
+	default:
+		k := multiStoreSplitPoint(len(items))
+		return multiStoreInnerHash(multiStoreSubsetHash(items[:k]), multiStoreSubsetHash(items[k:]))
+	}
+}
+
+// multiStoreProofPath recurses the same way multiStoreSubsetHash does,
+// collecting the sibling subtree's hash at each level on the path to idx.
+// The result is ordered leaf-to-root, matching MultiStoreProof.Sibling.
+func multiStoreProofPath(items [][]byte, idx int) []MultiStoreSibling {
+	if len(items) <= 1 {
+		return nil
+	}
+	k := multiStoreSplitPoint(len(items))
+	if idx < k {
+		path := multiStoreProofPath(items[:k], idx)
+		return append(path, MultiStoreSibling{Hash: multiStoreSubsetHash(items[k:]), Left: false})
+	}
+	path := multiStoreProofPath(items[k:], idx-k)
+	return append(path, MultiStoreSibling{Hash: multiStoreSubsetHash(items[:k]), Left: true})
+}
+
+// BuildMultiStoreProof builds a MultiStoreProof binding storeRoots[storeName]
+// into the outer simple Merkle tree formed over every store in storeRoots,
+// sorted by name the same way Cosmos SDK's multi-store commits them. It
+// returns the proof and the resulting app hash.
+func BuildMultiStoreProof(storeName string, storeRoots map[string][]byte) (*MultiStoreProof, []byte, error) {
+	if _, ok := storeRoots[storeName]; !ok {
+		return nil, nil, errors.Errorf("store %q not present in the given store roots", storeName)
+	}
+
+	names := make([]string, 0, len(storeRoots))
+	for name := range storeRoots {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	leaves := make([][]byte, len(names))
+	idx := -1
+	for i, name := range names {
+		leaves[i] = multiStoreLeafHash([]byte(name), storeRoots[name])
+		if name == storeName {
+			idx = i
+		}
+	}
+	if idx < 0 {
+		return nil, nil, errors.Errorf("internal error: store %q missing after sort", storeName)
+	}
+
+	appHash := multiStoreSubsetHash(leaves)
+	return &MultiStoreProof{
+		StoreName: []byte(storeName),
+		Sibling:   multiStoreProofPath(leaves, idx),
+	}, appHash, nil
+}
+
+// Verify walks the outer simple Merkle path from (msp.StoreName, subRoot) up
+// to appHash, applying each sibling hash in order. Callers are expected to
+// pass the sub-proof's already-verified RootHash as subRoot.
+func (msp *MultiStoreProof) Verify(subRoot, appHash []byte) error {
+	hash := multiStoreLeafHash(msp.StoreName, subRoot)
+	for _, sibling := range msp.Sibling {
+		if sibling.Left {
+			hash = multiStoreInnerHash(sibling.Hash, hash)
+		} else {
+			hash = multiStoreInnerHash(hash, sibling.Hash)
+		}
+	}
+	if !bytes.Equal(hash, appHash) {
+		return errors.Wrap(ErrInvalidRoot, "multi-store proof does not resolve to the given app hash")
+	}
+	return nil
+}
+
+// VerifyAgainstAppHash verifies that (key, value) exists in the IAVL tree
+// committed to by proof's own RootHash, and then that RootHash is bound to
+// appHash under storeName by msp. This lets a caller check a KeyExistsProof
+// end-to-end against a Tendermint block's AppHash without separately
+// re-implementing the multi-store hashing scheme.
+func (proof *KeyExistsProof) VerifyAgainstAppHash(msp *MultiStoreProof, appHash, storeName, key, value []byte) error {
+	if !bytes.Equal(msp.StoreName, storeName) {
+		return errors.Errorf("multi-store proof is for store %q, not %q", msp.StoreName, storeName)
+	}
+	if err := proof.Verify(key, value, proof.RootHash); err != nil {
+		return errors.Wrap(err, "inner IAVL proof failed to verify")
+	}
+	return msp.Verify(proof.RootHash, appHash)
+}
@@ -0,0 +1,47 @@
+package iavl
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildMultiStoreProofVerifiesAgainstAppHash(t *testing.T) {
+	storeRoots := map[string][]byte{
+		"acc":   []byte("acc-root"),
+		"bank":  []byte("bank-root"),
+		"gov":   []byte("gov-root"),
+		"stake": []byte("stake-root"),
+		"mint":  []byte("mint-root"),
+	}
+
+	for name := range storeRoots {
+		msp, appHash, err := BuildMultiStoreProof(name, storeRoots)
+		require.NoError(t, err, name)
+		require.NoError(t, msp.Verify(storeRoots[name], appHash), name)
+
+		// A proof for the wrong sub-root must not verify.
+		require.Error(t, msp.Verify([]byte("not-the-real-root"), appHash), name)
+	}
+}
+
+func TestKeyExistsProofVerifyAgainstAppHash(t *testing.T) {
+	tree := NewIAVLTree(0, nil)
+	for i := 0; i < 10; i++ {
+		tree.Set([]byte(fmt.Sprintf("key%02d", i)), []byte(fmt.Sprintf("val%02d", i)))
+	}
+	tree.Hash()
+
+	value, proof, err := tree.getWithProof([]byte("key05"))
+	require.NoError(t, err)
+
+	storeRoots := map[string][]byte{
+		"acc":  proof.RootHash,
+		"bank": []byte("bank-root"),
+	}
+	msp, appHash, err := BuildMultiStoreProof("acc", storeRoots)
+	require.NoError(t, err)
+
+	require.NoError(t, proof.VerifyAgainstAppHash(msp, appHash, []byte("acc"), []byte("key05"), value))
+}
@@ -0,0 +1,344 @@
+package iavl
+
+import (
+	"bytes"
+
+	"github.com/pkg/errors"
+	"github.com/tendermint/go-wire/data"
+)
+
+// KeyRangeProof proves that `Leaves` is a complete, correctly ordered run of
+// consecutive leaves under RootHash — including that no leaf was omitted
+// between two consecutive entries, or just outside either edge of the run.
+// The proof does not record start and end: Verify does not check that the
+// boundary leaves lie outside [start, end), and when GetRangeWithProof stops
+// at limit, RightBoundary is the next leaf inside the range.
+//
+// Proof size is kept to O(range + log N) rather than O(range * log N) by
+// sharing inner-node hashes between adjacent leaves: PathToKeys[0] is the
+// full root-to-leaf path for Leaves[0], but for i > 0, PathToKeys[i] holds
+// only the entries below the lowest common ancestor of Leaves[i-1] and
+// Leaves[i] — Shared[i] records how many entries, counted from the root end
+// of the reconstructed path for Leaves[i-1], complete it.
+type KeyRangeProof struct {
+	RootHash data.Bytes `json:"root_hash"`
+	Version  uint64     `json:"version"`
+
+	PathToKeys []*PathToKey        `json:"paths"`
+	Shared     []int               `json:"shared"`
+	Leaves     []IAVLProofLeafNode `json:"leaves"`
+
+	// LeftBoundary is a full existence proof for the leaf immediately
+	// preceding Leaves[0] in the whole tree (nil if Leaves[0] is already
+	// the tree's minimum key), used to prove nothing qualifying was
+	// omitted before the range's left edge.
+	LeftBoundary     *IAVLProofLeafNode `json:"left_boundary,omitempty"`
+	LeftBoundaryPath *PathToKey         `json:"left_boundary_path,omitempty"`
+
+	// RightBoundary is the analogous existence proof for the leaf
+	// immediately following the last entry of Leaves.
+	RightBoundary     *IAVLProofLeafNode `json:"right_boundary,omitempty"`
+	RightBoundaryPath *PathToKey         `json:"right_boundary_path,omitempty"`
+}
+
+// innerNodeEqual reports whether two IAVLProofInnerNode values describe the
+// same physical ancestor, reached the same way.
+func innerNodeEqual(a, b IAVLProofInnerNode) bool {
+	return a.Height == b.Height && a.Size == b.Size &&
+		bytes.Equal(a.Left, b.Left) && bytes.Equal(a.Right, b.Right)
+}
+
+// commonSuffixLen returns the length of the longest common suffix of a and
+// b, comparing entries with innerNodeEqual. Since InnerNodes is ordered
+// leaf-to-root, a shared suffix is exactly the set of ancestors two leaves'
+// paths have in common (everything above their lowest common ancestor).
+func commonSuffixLen(a, b []IAVLProofInnerNode) int {
+	n := 0
+	for n < len(a) && n < len(b) && innerNodeEqual(a[len(a)-1-n], b[len(b)-1-n]) {
+		n++
+	}
+	return n
+}
+
+// reconstructPath rebuilds the full leaf-to-root InnerNodes for entry i,
+// given the already-reconstructed full path for entry i-1 (or nil for i==0).
+func reconstructPath(divergent []IAVLProofInnerNode, shared int, prevFull []IAVLProofInnerNode) ([]IAVLProofInnerNode, error) {
+	if shared == 0 {
+		return divergent, nil
+	}
+	if shared > len(prevFull) {
+		return nil, errors.New("malformed range proof: shared count exceeds previous path length")
+	}
+	full := make([]IAVLProofInnerNode, 0, len(divergent)+shared)
+	full = append(full, divergent...)
+	full = append(full, prevFull[len(prevFull)-shared:]...)
+	return full, nil
+}
+
+// foldToRoot folds leafHash up through path (leaf-to-root order), the same
+// way IAVLProofInnerNode.Hash is applied by KeyExistsProof.Verify.
+func foldToRoot(leafHash []byte, path []IAVLProofInnerNode) []byte {
+	hash := leafHash
+	for _, inner := range path {
+		hash = inner.Hash(hash)
+	}
+	return hash
+}
+
+// checkAdjacent verifies that leftFull and rightFull (full leaf-to-root
+// paths for two leaves known to be in sorted order, with leftHash/rightHash
+// their leaf hashes) are immediate in-order neighbors: nothing exists
+// between them anywhere in the tree. It does this by locating their lowest
+// common ancestor (the first point both paths diverge, reading from the
+// leaf end) and checking that (a) the left leaf is the rightmost leaf of
+// the ancestor's left subtree, (b) the right leaf is the leftmost leaf of
+// the ancestor's right subtree, and (c) the sibling hashes recorded at the
+// ancestor are exactly the hashes each side's own sub-path folds up to.
+func checkAdjacent(leftFull, rightFull []IAVLProofInnerNode, leftHash, rightHash []byte) error {
+	shared := commonSuffixLen(leftFull, rightFull)
+	leftDivergent := leftFull[:len(leftFull)-shared]
+	rightDivergent := rightFull[:len(rightFull)-shared]
+	if len(leftDivergent) == 0 || len(rightDivergent) == 0 {
+		return errors.New("leaves do not share a lowest common ancestor")
+	}
+
+	lca := leftDivergent[len(leftDivergent)-1]
+	rcaOther := rightDivergent[len(rightDivergent)-1]
+	if lca.Height != rcaOther.Height || lca.Size != rcaOther.Size {
+		return errors.New("mismatched lowest common ancestor between adjacent leaves")
+	}
+	if len(lca.Left) != 0 {
+		return errors.New("left leaf did not descend left at the common ancestor")
+	}
+	if len(rcaOther.Right) != 0 {
+		return errors.New("right leaf did not descend right at the common ancestor")
+	}
+
+	// Nothing may be omitted within either subtree beneath the ancestor:
+	// the left leaf must be the max of its subtree (always went right
+	// below the ancestor), the right leaf must be the min of its subtree
+	// (always went left below the ancestor).
+	for _, e := range leftDivergent[:len(leftDivergent)-1] {
+		if len(e.Right) != 0 {
+			return errors.New("a leaf may have been omitted to the right of the range's left side")
+		}
+	}
+	for _, e := range rightDivergent[:len(rightDivergent)-1] {
+		if len(e.Left) != 0 {
+			return errors.New("a leaf may have been omitted to the left of the range's right side")
+		}
+	}
+
+	rightSubHash := foldToRoot(rightHash, rightDivergent[:len(rightDivergent)-1])
+	if !bytes.Equal(lca.Right, rightSubHash) {
+		return errors.New("right subtree hash does not match the common ancestor's recorded sibling")
+	}
+	leftSubHash := foldToRoot(leftHash, leftDivergent[:len(leftDivergent)-1])
+	if !bytes.Equal(rcaOther.Left, leftSubHash) {
+		return errors.New("left subtree hash does not match the common ancestor's recorded sibling")
+	}
+	return nil
+}
+
+// GetRangeWithProof returns the keys and values in [start, end) (or the
+// first `limit` of them, if limit > 0), along with a KeyRangeProof
+// attesting that this set is the complete, contiguous set of leaves in that
+// range under the current root. It performs a single in-order traversal of
+// the tree rather than calling pathToKey once per key, and shares ancestor
+// hashes between adjacent leaves rather than repeating them.
+func (t *IAVLTree) GetRangeWithProof(start, end []byte, limit int) (keys, values [][]byte, rangeProof *KeyRangeProof, err error) {
+	if t.root == nil {
+		return nil, nil, nil, ErrNilRoot
+	}
+	t.root.hashWithCount() // Ensure that all hashes are calculated.
+
+	var (
+		leaves    []IAVLProofLeafNode
+		fullPaths [][]IAVLProofInnerNode
+	)
+
+	var walk func(node *IAVLNode, stack []IAVLProofInnerNode) bool
+	walk = func(node *IAVLNode, stack []IAVLProofInnerNode) bool {
+		if node.height == 0 {
+			if bytes.Compare(node.key, start) < 0 || (end != nil && bytes.Compare(node.key, end) >= 0) {
+				return false
+			}
+			if limit > 0 && len(leaves) >= limit {
+				return true
+			}
+			full := make([]IAVLProofInnerNode, len(stack))
+			for i, s := range stack {
+				full[len(stack)-1-i] = s
+			}
+			fullPaths = append(fullPaths, full)
+			leaves = append(leaves, IAVLProofLeafNode{
+				KeyBytes:   node.key,
+				ValueBytes: node.value,
+				Version:    node.version,
+			})
+			keys = append(keys, node.key)
+			values = append(values, node.value)
+			return false
+		}
+
+		left := node.getLeftNode(t)
+		right := node.getRightNode(t)
+
+		if bytes.Compare(start, node.key) < 0 {
+			branch := IAVLProofInnerNode{Height: node.height, Size: node.size, Left: nil, Right: right.hash}
+			if walk(left, append(stack, branch)) {
+				return true
+			}
+		}
+		if end == nil || bytes.Compare(node.key, end) < 0 {
+			branch := IAVLProofInnerNode{Height: node.height, Size: node.size, Left: left.hash, Right: nil}
+			if walk(right, append(stack, branch)) {
+				return true
+			}
+		}
+		return false
+	}
+	walk(t.root, nil)
+
+	if len(leaves) == 0 {
+		return nil, nil, nil, errors.New("no keys found in range")
+	}
+
+	paths := make([]*PathToKey, len(leaves))
+	shared := make([]int, len(leaves))
+	paths[0] = &PathToKey{InnerNodes: fullPaths[0]}
+	for i := 1; i < len(leaves); i++ {
+		s := commonSuffixLen(fullPaths[i-1], fullPaths[i])
+		shared[i] = s
+		paths[i] = &PathToKey{InnerNodes: append([]IAVLProofInnerNode{}, fullPaths[i][:len(fullPaths[i])-s]...)}
+	}
+
+	rangeProof = &KeyRangeProof{
+		RootHash:   t.root.hash,
+		Version:    t.root.version,
+		PathToKeys: paths,
+		Shared:     shared,
+		Leaves:     leaves,
+	}
+
+	// Boundary proofs: bracket the range with the immediate neighbors
+	// outside it, if any, so a verifier can confirm nothing qualifying was
+	// omitted at either edge.
+	firstIdx, _, exists := t.Get(leaves[0].KeyBytes)
+	if !exists {
+		return nil, nil, nil, errors.New("internal error: first leaf not found by key")
+	}
+	if firstIdx > 0 {
+		lkey, lval := t.GetByIndex(firstIdx - 1)
+		lPath, lNode, perr := t.cachedPathToKey(t.root, lkey)
+		if perr != nil {
+			return nil, nil, nil, errors.Wrap(perr, "could not construct left boundary proof")
+		}
+		leaf := IAVLProofLeafNode{KeyBytes: lkey, ValueBytes: lval, Version: lNode.version}
+		rangeProof.LeftBoundary = &leaf
+		rangeProof.LeftBoundaryPath = &PathToKey{InnerNodes: lPath.InnerNodes}
+	}
+
+	lastIdx := firstIdx + len(leaves) - 1
+	if lastIdx < t.Size()-1 {
+		rkey, rval := t.GetByIndex(lastIdx + 1)
+		rPath, rNode, perr := t.cachedPathToKey(t.root, rkey)
+		if perr != nil {
+			return nil, nil, nil, errors.Wrap(perr, "could not construct right boundary proof")
+		}
+		leaf := IAVLProofLeafNode{KeyBytes: rkey, ValueBytes: rval, Version: rNode.version}
+		rangeProof.RightBoundary = &leaf
+		rangeProof.RightBoundaryPath = &PathToKey{InnerNodes: rPath.InnerNodes}
+	}
+
+	return keys, values, rangeProof, nil
+}
+
+// Verify recomputes the root hash implied by each leaf and its (possibly
+// compacted) path, checks the result against root, confirms Leaves is
+// strictly increasing, and confirms adjacency between every consecutive
+// pair of leaves — plus, where present, the left/right boundary leaves, or
+// else that the edge leaf is the tree's leftmost/rightmost leaf — so that
+// no key could have been silently dropped from within or beside the run.
+func (proof *KeyRangeProof) Verify(keys, values [][]byte, root []byte) error {
+	if len(keys) != len(values) || len(keys) != len(proof.Leaves) {
+		return errors.New("keys, values and leaves must be the same length")
+	}
+	if len(proof.Leaves) != len(proof.PathToKeys) || len(proof.Leaves) != len(proof.Shared) {
+		return errors.New("malformed range proof: leaves, paths and shared counts length mismatch")
+	}
+	if !bytes.Equal(proof.RootHash, root) {
+		return errors.WithStack(ErrInvalidRoot)
+	}
+
+	fullPaths := make([][]IAVLProofInnerNode, len(proof.Leaves))
+	leafHashes := make([][]byte, len(proof.Leaves))
+
+	for i, leaf := range proof.Leaves {
+		if !bytes.Equal(leaf.KeyBytes, keys[i]) || !bytes.Equal(leaf.ValueBytes, values[i]) {
+			return errors.Errorf("leaf %d does not match provided key/value", i)
+		}
+		if i > 0 && bytes.Compare(proof.Leaves[i-1].KeyBytes, leaf.KeyBytes) >= 0 {
+			return errors.New("leaves are not strictly increasing: range is not contiguous")
+		}
+
+		var prev []IAVLProofInnerNode
+		if i > 0 {
+			prev = fullPaths[i-1]
+		}
+		full, err := reconstructPath(proof.PathToKeys[i].InnerNodes, proof.Shared[i], prev)
+		if err != nil {
+			return err
+		}
+		fullPaths[i] = full
+		leafHashes[i] = leaf.Hash()
+
+		if !bytes.Equal(foldToRoot(leafHashes[i], full), root) {
+			return errors.Errorf("leaf %d: calculated root does not match provided root", i)
+		}
+
+		if i > 0 {
+			if err := checkAdjacent(fullPaths[i-1], full, leafHashes[i-1], leafHashes[i]); err != nil {
+				return errors.Wrapf(err, "leaves %d and %d are not adjacent", i-1, i)
+			}
+		}
+	}
+
+	if proof.LeftBoundary != nil {
+		if bytes.Compare(proof.LeftBoundary.KeyBytes, proof.Leaves[0].KeyBytes) >= 0 {
+			return errors.New("left boundary leaf is not less than the range's first key")
+		}
+		if !bytes.Equal(foldToRoot(proof.LeftBoundary.Hash(), proof.LeftBoundaryPath.InnerNodes), root) {
+			return errors.New("left boundary leaf does not verify against root")
+		}
+		if err := checkAdjacent(proof.LeftBoundaryPath.InnerNodes, fullPaths[0], proof.LeftBoundary.Hash(), leafHashes[0]); err != nil {
+			return errors.Wrap(err, "left boundary is not adjacent to the range's first leaf")
+		}
+	} else {
+		for _, e := range fullPaths[0] {
+			if len(e.Left) != 0 {
+				return errors.New("no left boundary, but the range's first leaf is not the tree's leftmost leaf")
+			}
+		}
+	}
+	last := len(proof.Leaves) - 1
+	if proof.RightBoundary != nil {
+		if bytes.Compare(proof.RightBoundary.KeyBytes, proof.Leaves[last].KeyBytes) <= 0 {
+			return errors.New("right boundary leaf is not greater than the range's last key")
+		}
+		if !bytes.Equal(foldToRoot(proof.RightBoundary.Hash(), proof.RightBoundaryPath.InnerNodes), root) {
+			return errors.New("right boundary leaf does not verify against root")
+		}
+		if err := checkAdjacent(fullPaths[last], proof.RightBoundaryPath.InnerNodes, leafHashes[last], proof.RightBoundary.Hash()); err != nil {
+			return errors.Wrap(err, "right boundary is not adjacent to the range's last leaf")
+		}
+	} else {
+		for _, e := range fullPaths[last] {
+			if len(e.Right) != 0 {
+				return errors.New("no right boundary, but the range's last leaf is not the tree's rightmost leaf")
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package iavl
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func buildRangeTestTree(t *testing.T) *IAVLTree {
+	tree := NewIAVLTree(0, nil)
+	for i := 0; i < 50; i++ {
+		tree.Set([]byte(fmt.Sprintf("key%03d", i)), []byte(fmt.Sprintf("value%03d", i)))
+	}
+	tree.Hash()
+	return tree
+}
+
+func TestGetRangeWithProofVerifies(t *testing.T) {
+	tree := buildRangeTestTree(t)
+
+	keys, values, proof, err := tree.GetRangeWithProof([]byte("key010"), []byte("key020"), 0)
+	require.NoError(t, err)
+	require.Len(t, keys, 10)
+	require.NoError(t, proof.Verify(keys, values, tree.root.hash))
+}
+
+func TestGetRangeWithProofRejectsOmittedLeaf(t *testing.T) {
+	tree := buildRangeTestTree(t)
+
+	keys, values, proof, err := tree.GetRangeWithProof([]byte("key010"), []byte("key020"), 0)
+	require.NoError(t, err)
+
+	// Drop an interior leaf (and its path) the way a dishonest prover
+	// might, to omit a key from the middle of the range.
+	omit := len(keys) / 2
+	keys = append(append([][]byte{}, keys[:omit]...), keys[omit+1:]...)
+	values = append(append([][]byte{}, values[:omit]...), values[omit+1:]...)
+	proof.Leaves = append(append([]IAVLProofLeafNode{}, proof.Leaves[:omit]...), proof.Leaves[omit+1:]...)
+	proof.PathToKeys = append(append([]*PathToKey{}, proof.PathToKeys[:omit]...), proof.PathToKeys[omit+1:]...)
+	proof.Shared = append(append([]int{}, proof.Shared[:omit]...), proof.Shared[omit+1:]...)
+
+	require.Error(t, proof.Verify(keys, values, tree.root.hash))
+}
+
+func TestGetRangeWithProofRejectsOmittedBoundaryLeaf(t *testing.T) {
+	tree := buildRangeTestTree(t)
+
+	keys, values, proof, err := tree.GetRangeWithProof([]byte("key010"), []byte("key020"), 0)
+	require.NoError(t, err)
+
+	// Drop the first leaf without adjusting the left boundary proof: the
+	// remaining range should no longer verify as complete against the
+	// original boundary, since it no longer brackets the new first key.
+	keys = keys[1:]
+	values = values[1:]
+	proof.Leaves = proof.Leaves[1:]
+	proof.Shared = proof.Shared[1:]
+	proof.Shared[0] = 0
+	proof.PathToKeys = proof.PathToKeys[1:]
+
+	// Give the new first leaf its full path, so that only the left boundary
+	// check stands between this proof and a successful Verify.
+	firstPath, _, err := tree.root.pathToKey(tree, keys[0])
+	require.NoError(t, err)
+	proof.PathToKeys[0] = firstPath
+
+	require.Error(t, proof.Verify(keys, values, tree.root.hash))
+}
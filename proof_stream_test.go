@@ -0,0 +1,50 @@
+package iavl
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// nonSizedReader wraps a reader without exposing a Len method, so it's
+// representative of a non-seekable network stream rather than something
+// that already buffers the whole value (e.g. *bytes.Reader).
+type nonSizedReader struct {
+	r io.Reader
+}
+
+func (r *nonSizedReader) Read(p []byte) (int, error) {
+	return r.r.Read(p)
+}
+
+func TestVerifyStreamWithPlainReader(t *testing.T) {
+	tree := NewIAVLTree(0, nil)
+	for i := 0; i < 20; i++ {
+		tree.Set([]byte(fmt.Sprintf("key%03d", i)), []byte(fmt.Sprintf("value%03d", i)))
+	}
+	tree.Hash()
+
+	value, proof, err := tree.getWithProof([]byte("key010"))
+	require.NoError(t, err)
+
+	reader := &nonSizedReader{r: bytes.NewReader(value)}
+	require.NoError(t, proof.VerifyStream([]byte("key010"), reader, int64(len(value)), tree.root.hash))
+}
+
+func TestVerifyStreamRejectsWrongValue(t *testing.T) {
+	tree := NewIAVLTree(0, nil)
+	for i := 0; i < 20; i++ {
+		tree.Set([]byte(fmt.Sprintf("key%03d", i)), []byte(fmt.Sprintf("value%03d", i)))
+	}
+	tree.Hash()
+
+	_, proof, err := tree.getWithProof([]byte("key010"))
+	require.NoError(t, err)
+
+	wrongValue := []byte("not-the-real-value")
+	reader := &nonSizedReader{r: bytes.NewReader(wrongValue)}
+	require.Error(t, proof.VerifyStream([]byte("key010"), reader, int64(len(wrongValue)), tree.root.hash))
+}